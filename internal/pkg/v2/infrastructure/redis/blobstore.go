@@ -0,0 +1,253 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// readingBlobRefKey is the key of the side record that maps a reading id to the BlobReference describing where its
+// binary payload was persisted.
+func readingBlobRefKey(id string) string {
+	return fmt.Sprintf("%s:blob:%s", ReadingsCollection, id)
+}
+
+// deleteReadingBlobsByIds deletes the out-of-band payloads referenced by the given reading ids from blobStore.
+// Must be called before the readingBlobRefKey records are removed, and outside of any pipeline.
+func deleteReadingBlobsByIds(ctx context.Context, conn redis.Cmdable, blobStore BlobStore, loggingClient logger.LoggingClient, readingIds []string) {
+	for _, id := range readingIds {
+		refBytes, err := conn.Get(ctx, readingBlobRefKey(id)).Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			loggingClient.Error(fmt.Sprintf("unable to retrieve blob reference for reading[id:%s].  Err: %s", id, err.Error()))
+			continue
+		}
+
+		var ref BlobReference
+		if err := json.Unmarshal(refBytes, &ref); err != nil {
+			loggingClient.Error(fmt.Sprintf("unable to parse blob reference for reading[id:%s].  Err: %s", id, err.Error()))
+			continue
+		}
+		if delErr := blobStore.Delete(ctx, ref.URI); delErr != nil {
+			loggingClient.Error(fmt.Sprintf("unable to delete blob[uri:%s] for reading[id:%s].  Err: %s", ref.URI, id, delErr.DebugMessages()))
+		}
+	}
+}
+
+// readingBlobReference returns the BlobReference recorded for the given reading id, if any.
+func readingBlobReference(ctx context.Context, conn redis.Cmdable, id string) (ref BlobReference, exists bool, edgeXerr errors.EdgeX) {
+	refBytes, err := conn.Get(ctx, readingBlobRefKey(id)).Bytes()
+	if err == redis.Nil {
+		return BlobReference{}, false, nil
+	} else if err != nil {
+		return BlobReference{}, false, errors.NewCommonEdgeX(errors.KindDatabaseError, fmt.Sprintf("reading[id:%s] blob reference lookup failed", id), err)
+	}
+	if err := json.Unmarshal(refBytes, &ref); err != nil {
+		return BlobReference{}, false, errors.NewCommonEdgeX(errors.KindDatabaseError, "blob reference format parsing failed", err)
+	}
+	return ref, true, nil
+}
+
+// ReadingBinaryValue resolves the out-of-band payload for reading id, returning errors.KindEntityDoesNotExist
+// if it has no associated blob. It has no streaming HTTP handler yet, so it is not reachable via any route.
+func (c *Client) ReadingBinaryValue(ctx context.Context, id string) (data []byte, mediaType string, edgeXerr errors.EdgeX) {
+	if c.BlobStore == nil {
+		return nil, "", errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, "no blob store is configured", nil)
+	}
+
+	ref, exists, edgeXerr := readingBlobReference(ctx, c.Client, id)
+	if edgeXerr != nil {
+		return nil, "", edgeXerr
+	}
+	if !exists {
+		return nil, "", errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("reading[id:%s] has no associated blob", id), nil)
+	}
+
+	data, edgeXerr = c.BlobStore.Get(ctx, ref.URI)
+	if edgeXerr != nil {
+		return nil, "", edgeXerr
+	}
+	return data, ref.MediaType, nil
+}
+
+// BlobReference is the content-addressable pointer to a reading's out-of-band binary payload. It is stored in a side
+// record under readingBlobRefKey, not on the reading document itself.
+type BlobReference struct {
+	URI       string `json:"uri"`
+	Size      int64  `json:"size"`
+	MediaType string `json:"mediaType"`
+}
+
+// BlobStore persists binary reading payloads out-of-band from the reading document itself, keyed by the sha256 of
+// the payload so that identical payloads are only ever stored once.
+type BlobStore interface {
+	// Put stores data and returns the URI under which it can later be retrieved.
+	Put(ctx context.Context, data []byte, mediaType string) (uri string, edgeXerr errors.EdgeX)
+	// Get retrieves the payload previously stored under uri.
+	Get(ctx context.Context, uri string) (data []byte, edgeXerr errors.EdgeX)
+	// Delete removes the payload previously stored under uri. Deleting a uri that does not exist is not an error.
+	Delete(ctx context.Context, uri string) (edgeXerr errors.EdgeX)
+}
+
+// blobReferenceFor builds the content-addressable reference for a payload without storing it.
+func blobReferenceFor(data []byte, mediaType, uri string) BlobReference {
+	return BlobReference{
+		URI:       uri,
+		Size:      int64(len(data)),
+		MediaType: mediaType,
+	}
+}
+
+func sha256URI(scheme string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s://sha256/%s", scheme, hex.EncodeToString(sum[:]))
+}
+
+// FileBlobStore persists binary payloads as individual files underneath a base directory, named by the sha256 of
+// their contents.
+type FileBlobStore struct {
+	BaseDir string
+}
+
+// NewFileBlobStore creates a FileBlobStore rooted at baseDir, creating the directory if it does not already exist.
+func NewFileBlobStore(baseDir string) (*FileBlobStore, errors.EdgeX) {
+	if err := os.MkdirAll(baseDir, 0o750); err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, "failed to create blob store base directory", err)
+	}
+	return &FileBlobStore{BaseDir: baseDir}, nil
+}
+
+func (f *FileBlobStore) pathFor(uri string) string {
+	return filepath.Join(f.BaseDir, filepath.Base(uri))
+}
+
+func (f *FileBlobStore) Put(_ context.Context, data []byte, mediaType string) (string, errors.EdgeX) {
+	uri := sha256URI("file", data)
+	if err := ioutil.WriteFile(f.pathFor(uri), data, 0o640); err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, "failed to write blob to filesystem", err)
+	}
+	return uri, nil
+}
+
+func (f *FileBlobStore) Get(_ context.Context, uri string) ([]byte, errors.EdgeX) {
+	data, err := ioutil.ReadFile(f.pathFor(uri))
+	if os.IsNotExist(err) {
+		return nil, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("blob[uri:%s] does not exist", uri), err)
+	} else if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, "failed to read blob from filesystem", err)
+	}
+	return data, nil
+}
+
+func (f *FileBlobStore) Delete(_ context.Context, uri string) errors.EdgeX {
+	if err := os.Remove(f.pathFor(uri)); err != nil && !os.IsNotExist(err) {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to remove blob from filesystem", err)
+	}
+	return nil
+}
+
+// S3ObjectAPI is the subset of an S3-compatible client that S3BlobStore depends on, allowing callers to plug in
+// any SDK (AWS, MinIO, etc.) that can satisfy it.
+type S3ObjectAPI interface {
+	PutObject(bucket, key string, data []byte, mediaType string) error
+	GetObject(bucket, key string) ([]byte, error)
+	DeleteObject(bucket, key string) error
+}
+
+// S3BlobStore persists binary payloads to an S3-compatible object store, one object per payload, named by the
+// sha256 of their contents.
+type S3BlobStore struct {
+	Client S3ObjectAPI
+	Bucket string
+}
+
+// NewS3BlobStore creates an S3BlobStore backed by client, storing objects in bucket.
+func NewS3BlobStore(client S3ObjectAPI, bucket string) *S3BlobStore {
+	return &S3BlobStore{Client: client, Bucket: bucket}
+}
+
+func (s *S3BlobStore) keyFor(uri string) string {
+	return filepath.Base(uri)
+}
+
+func (s *S3BlobStore) Put(_ context.Context, data []byte, mediaType string) (string, errors.EdgeX) {
+	uri := sha256URI("s3", data)
+	if err := s.Client.PutObject(s.Bucket, s.keyFor(uri), data, mediaType); err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, "failed to put blob to object store", err)
+	}
+	return uri, nil
+}
+
+func (s *S3BlobStore) Get(_ context.Context, uri string) ([]byte, errors.EdgeX) {
+	data, err := s.Client.GetObject(s.Bucket, s.keyFor(uri))
+	if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, "failed to get blob from object store", err)
+	}
+	return data, nil
+}
+
+func (s *S3BlobStore) Delete(_ context.Context, uri string) errors.EdgeX {
+	if err := s.Client.DeleteObject(s.Bucket, s.keyFor(uri)); err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to delete blob from object store", err)
+	}
+	return nil
+}
+
+// RedisBlobStore persists binary payloads as plain Redis strings, keyed by the sha256 of their contents. It is
+// intended for small blobs where the operational simplicity of a single backing store outweighs the extra memory
+// pressure on Redis.
+const BlobCollection = "v2:blob"
+
+type RedisBlobStore struct {
+	Client redis.UniversalClient
+}
+
+// NewRedisBlobStore creates a RedisBlobStore backed by client.
+func NewRedisBlobStore(client redis.UniversalClient) *RedisBlobStore {
+	return &RedisBlobStore{Client: client}
+}
+
+func (r *RedisBlobStore) keyFor(uri string) string {
+	return fmt.Sprintf("%s:%s", BlobCollection, filepath.Base(uri))
+}
+
+func (r *RedisBlobStore) Put(ctx context.Context, data []byte, mediaType string) (string, errors.EdgeX) {
+	uri := sha256URI("redis", data)
+	if err := r.Client.Set(ctx, r.keyFor(uri), data, 0).Err(); err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to put blob to redis", err)
+	}
+	return uri, nil
+}
+
+func (r *RedisBlobStore) Get(ctx context.Context, uri string) ([]byte, errors.EdgeX) {
+	data, err := r.Client.Get(ctx, r.keyFor(uri)).Bytes()
+	if err == redis.Nil {
+		return nil, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("blob[uri:%s] does not exist", uri), err)
+	} else if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to get blob from redis", err)
+	}
+	return data, nil
+}
+
+func (r *RedisBlobStore) Delete(ctx context.Context, uri string) errors.EdgeX {
+	if err := r.Client.Unlink(ctx, r.keyFor(uri)).Err(); err != nil {
+		return errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to delete blob from redis", err)
+	}
+	return nil
+}
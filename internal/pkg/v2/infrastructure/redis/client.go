@@ -0,0 +1,50 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// defaultBatchSize bounds how many Redis commands the batch-oriented helpers in this package (e.g.
+// asyncDeleteReadingsByIds, ReadingsDownsampled) queue into a single pipeline round trip.
+const defaultBatchSize = 1000
+
+// Client is the go-redis-backed implementation of the v2 reading persistence API.
+type Client struct {
+	Client           redis.UniversalClient
+	loggingClient    logger.LoggingClient
+	BatchSize        int
+	BlobStore        BlobStore
+	Retention        RetentionConfig
+	RetentionMetrics *RetentionMetrics
+}
+
+// NewClient builds a Client from cfg, pinging the server to fail fast on misconfiguration, and starts the
+// retention sweeper described by retentionCfg. The returned stop function must be called on shutdown.
+func NewClient(ctx context.Context, cfg RedisClientConfig, loggingClient logger.LoggingClient, blobStore BlobStore, retentionCfg RetentionConfig) (client *Client, stop func(), edgeXerr errors.EdgeX) {
+	universalClient := newUniversalClient(cfg)
+	if err := universalClient.Ping(ctx).Err(); err != nil {
+		return nil, nil, errors.NewCommonEdgeX(errors.KindServerError, "failed to connect to redis", err)
+	}
+
+	metrics := &RetentionMetrics{}
+	client = &Client{
+		Client:           universalClient,
+		loggingClient:    loggingClient,
+		BatchSize:        defaultBatchSize,
+		BlobStore:        blobStore,
+		Retention:        retentionCfg,
+		RetentionMetrics: metrics,
+	}
+
+	return client, client.StartRetentionSweeper(ctx, retentionCfg, metrics), nil
+}
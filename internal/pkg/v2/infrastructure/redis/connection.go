@@ -0,0 +1,40 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisClientConfig holds the connection settings needed to build a go-redis client, including whether to address
+// the backing Redis deployment as a single node/sentinel or as a cluster.
+type RedisClientConfig struct {
+	// Hosts is a comma-separated list of host:port addresses. A single address is used to build a *redis.Client;
+	// multiple addresses, or ClusterMode set explicitly, build a *redis.ClusterClient.
+	Hosts       string
+	Password    string
+	ClusterMode bool
+}
+
+// newUniversalClient builds the redis.UniversalClient appropriate for cfg: a *redis.ClusterClient when ClusterMode
+// is enabled or multiple hosts are configured, otherwise a plain *redis.Client.
+func newUniversalClient(cfg RedisClientConfig) redis.UniversalClient {
+	addrs := strings.Split(cfg.Hosts, ",")
+
+	if cfg.ClusterMode || len(addrs) > 1 {
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    addrs,
+			Password: cfg.Password,
+		})
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:     addrs[0],
+		Password: cfg.Password,
+	})
+}
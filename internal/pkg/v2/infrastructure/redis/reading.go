@@ -6,45 +6,59 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"github.com/edgexfoundry/edgex-go/internal/pkg/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
 	"github.com/edgexfoundry/go-mod-core-contracts/errors"
 	"github.com/edgexfoundry/go-mod-core-contracts/v2"
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
 
-	"github.com/gomodule/redigo/redis"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 )
 
 const (
-	ReadingsCollection           = "v2:reading"
-	ReadingsCollectionCreated    = ReadingsCollection + ":" + v2.Created
-	ReadingsCollectionDeviceName = ReadingsCollection + ":" + v2.Device + ":" + v2.Name
+	ReadingsCollection                   = "v2:reading"
+	ReadingsCollectionCreated            = ReadingsCollection + ":" + v2.Created
+	ReadingsCollectionDeviceName         = ReadingsCollection + ":" + v2.Device + ":" + v2.Name
+	ReadingsCollectionResourceName       = ReadingsCollection + ":resourceName"
+	ReadingsCollectionDeviceResourceName = ReadingsCollectionDeviceName + ":resource"
 )
 
+// readingDeviceResourceIndexKey is the index scoped to a single device and resource, used by ReadingsDownsampled so
+// a range query does not have to pull every reading for the device just to discard the other resources.
+func readingDeviceResourceIndexKey(deviceName, resourceName string) string {
+	return fmt.Sprintf("%s:%s:%s", ReadingsCollectionDeviceResourceName, deviceName, resourceName)
+}
+
 var emptyBinaryValue = make([]byte, 0)
 
 // asyncDeleteReadingsByIds deletes all readings with given reading Ids.  This function is implemented to be run as a
-// separate gorountine in the background to achieve better performance, so this function return nothing.  When
-// encountering any errors during deletion, this function will simply log the error.
-func (c *Client) asyncDeleteReadingsByIds(readingIds []string) {
-	conn := c.Pool.Get()
-	defer conn.Close()
-
+// separate gorountine in the background to achieve better performance.  When encountering any errors during
+// deletion, this function will simply log the error. It returns the number of readings actually confirmed deleted,
+// which may be less than len(readingIds) if a batch's Exec fails.
+func (c *Client) asyncDeleteReadingsByIds(ctx context.Context, readingIds []string) (deletedCount int) {
 	var readings [][]byte
 	//start a transaction to get all readings
-	readings, edgeXerr := getObjectsByIds(conn, common.ConvertStringsToInterfaces(readingIds))
+	readings, edgeXerr := getObjectsByIds(ctx, c.Client, common.ConvertStringsToInterfaces(readingIds))
 	if edgeXerr != nil {
 		c.loggingClient.Error(fmt.Sprintf("Deleted readings failed while retrieving objects by Ids.  Err: %s", edgeXerr.DebugMessages()))
-		return
+		return 0
+	}
+
+	// resolve and delete any out-of-band blobs up front since blob deletion talks to the configured BlobStore
+	// rather than Redis itself, and so cannot be queued inside the pipeline below
+	if c.BlobStore != nil {
+		deleteReadingBlobsByIds(ctx, c.Client, c.BlobStore, c.loggingClient, readingIds)
 	}
 
 	// iterate each readings for deletion in batch
 	queriesInQueue := 0
 	r := models.BaseReading{}
-	_ = conn.Send(MULTI)
+	pipe := c.Client.TxPipeline()
 	for i, reading := range readings {
 		err := json.Unmarshal(reading, &r)
 		if err != nil {
@@ -52,33 +66,47 @@ func (c *Client) asyncDeleteReadingsByIds(readingIds []string) {
 			continue
 		}
 		storedKey := readingStoredKey(r.Id)
-		_ = conn.Send(UNLINK, storedKey)
-		_ = conn.Send(ZREM, ReadingsCollection, storedKey)
-		_ = conn.Send(ZREM, ReadingsCollectionCreated, storedKey)
-		_ = conn.Send(ZREM, fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, r.DeviceName), storedKey)
+		pipe.Unlink(ctx, storedKey)
+		pipe.ZRem(ctx, ReadingsCollection, storedKey)
+		pipe.ZRem(ctx, ReadingsCollectionCreated, storedKey)
+		pipe.ZRem(ctx, fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, r.DeviceName), storedKey)
+		pipe.ZRem(ctx, fmt.Sprintf("%s:%s", ReadingsCollectionResourceName, r.ResourceName), storedKey)
+		pipe.ZRem(ctx, readingDeviceResourceIndexKey(r.DeviceName, r.ResourceName), storedKey)
+		if c.BlobStore != nil {
+			pipe.Unlink(ctx, readingBlobRefKey(r.Id))
+		}
 		queriesInQueue++
 
 		if queriesInQueue >= c.BatchSize {
-			_, err = conn.Do(EXEC)
+			_, err = pipe.Exec(ctx)
 			if err != nil {
 				c.loggingClient.Error(fmt.Sprintf("unable to execute batch reading deletion.  Err: %s", err.Error()))
+				queriesInQueue = 0
+				if i < len(readings)-1 {
+					pipe = c.Client.TxPipeline()
+				}
 				continue
 			}
-			// reset queriesInQueue to zero if EXEC is successfully executed without error
+			deletedCount += queriesInQueue
+			// reset queriesInQueue to zero if Exec is successfully executed without error
 			queriesInQueue = 0
-			// rerun another transaction when reading iteration is not finished
+			// start a fresh pipeline when reading iteration is not finished
 			if i < len(readings)-1 {
-				_ = conn.Send(MULTI)
+				pipe = c.Client.TxPipeline()
 			}
 		}
 	}
 
 	if queriesInQueue > 0 {
-		_, err := conn.Do(EXEC)
+		_, err := pipe.Exec(ctx)
 		if err != nil {
 			c.loggingClient.Error(fmt.Sprintf("unable to execute batch reading deletion.  Err: %s", err.Error()))
+		} else {
+			deletedCount += queriesInQueue
 		}
 	}
+
+	return deletedCount
 }
 
 // readingStoredKey return the reading's stored key which combines the collection name and object id
@@ -86,20 +114,61 @@ func readingStoredKey(id string) string {
 	return fmt.Sprintf("%s:%s", ReadingsCollection, id)
 }
 
-// Add a reading to the database
-func addReading(conn redis.Conn, r models.Reading) (reading models.Reading, edgeXerr errors.EdgeX) {
+// readingDeviceAndResourceName extracts the device and resource name common to every models.Reading variant.
+func readingDeviceAndResourceName(r models.Reading) (deviceName string, resourceName string, ok bool) {
+	switch t := r.(type) {
+	case models.BinaryReading:
+		return t.DeviceName, t.ResourceName, true
+	case models.SimpleReading:
+		return t.DeviceName, t.ResourceName, true
+	default:
+		return "", "", false
+	}
+}
+
+// Add a reading to the database. When blobStore is non-nil, a BinaryReading's payload is persisted out-of-band
+// through it and a BlobReference to it is recorded under readingBlobRefKey; the reading document itself does not
+// carry the reference. When blobStore is nil the payload is discarded as before to save memory.
+func addReading(ctx context.Context, conn redis.Cmdable, blobStore BlobStore, profileCache DeviceResourceLookup, r models.Reading) (reading models.Reading, edgeXerr errors.EdgeX) {
+	if deviceName, resourceName, ok := readingDeviceAndResourceName(r); ok {
+		if checkErr := checkResourceIsReadable(profileCache, deviceName, resourceName); checkErr != nil {
+			return nil, checkErr
+		}
+	}
+
 	var m []byte
 	var err error
 	var baseReading *models.BaseReading
+	var blobRef *BlobReference
+	var writtenBlobURI string
+	// cleanupBlob removes a blob already written to blobStore when a later step in this call fails, so a rejected
+	// or failed write never leaves an unreferenced blob behind.
+	cleanupBlob := func() {
+		if writtenBlobURI != "" {
+			_ = blobStore.Delete(ctx, writtenBlobURI)
+		}
+	}
+
 	switch newReading := r.(type) {
 	case models.BinaryReading:
-		// Clear the binary data since we do not want to persist binary data to save on memory.
-		newReading.BinaryValue = emptyBinaryValue
-
 		baseReading = &newReading.BaseReading
 		if err = checkReadingValue(baseReading); err != nil {
 			return nil, errors.NewCommonEdgeXWrapper(err)
 		}
+
+		if blobStore != nil && len(newReading.BinaryValue) > 0 {
+			uri, putErr := blobStore.Put(ctx, newReading.BinaryValue, newReading.MediaType)
+			if putErr != nil {
+				return nil, errors.NewCommonEdgeXWrapper(putErr)
+			}
+			writtenBlobURI = uri
+			ref := blobReferenceFor(newReading.BinaryValue, newReading.MediaType, uri)
+			blobRef = &ref
+		}
+		// Clear the binary data since we do not want to persist binary data inline to save on memory; it now
+		// either lives in the configured BlobStore, referenced by blobRef, or is discarded entirely.
+		newReading.BinaryValue = emptyBinaryValue
+
 		m, err = json.Marshal(newReading)
 		reading = newReading
 	case models.SimpleReading:
@@ -114,34 +183,78 @@ func addReading(conn redis.Conn, r models.Reading) (reading models.Reading, edge
 	}
 
 	if err != nil {
+		cleanupBlob()
 		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "reading parsing failed", err)
 	}
+
 	storedKey := readingStoredKey(baseReading.Id)
+	pipe := conn.TxPipeline()
 	// use the SET command to save reading as blob
-	_ = conn.Send(SET, storedKey, m)
-	_ = conn.Send(ZADD, ReadingsCollection, 0, storedKey)
-	_ = conn.Send(ZADD, ReadingsCollectionCreated, baseReading.Created, storedKey)
-	_ = conn.Send(ZADD, fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, baseReading.DeviceName), baseReading.Created, storedKey)
+	pipe.Set(ctx, storedKey, m, 0)
+	pipe.ZAdd(ctx, ReadingsCollection, &redis.Z{Score: 0, Member: storedKey})
+	pipe.ZAdd(ctx, ReadingsCollectionCreated, &redis.Z{Score: float64(baseReading.Created), Member: storedKey})
+	pipe.ZAdd(ctx, fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, baseReading.DeviceName), &redis.Z{Score: float64(baseReading.Created), Member: storedKey})
+	pipe.ZAdd(ctx, fmt.Sprintf("%s:%s", ReadingsCollectionResourceName, baseReading.ResourceName), &redis.Z{Score: float64(baseReading.Created), Member: storedKey})
+	pipe.ZAdd(ctx, readingDeviceResourceIndexKey(baseReading.DeviceName, baseReading.ResourceName), &redis.Z{Score: float64(baseReading.Created), Member: storedKey})
+	if blobRef != nil {
+		refBytes, refErr := json.Marshal(blobRef)
+		if refErr != nil {
+			cleanupBlob()
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "blob reference parsing failed", refErr)
+		}
+		pipe.Set(ctx, readingBlobRefKey(baseReading.Id), refBytes, 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		cleanupBlob()
+		return nil, errors.NewCommonEdgeX(errors.KindDatabaseError, fmt.Sprintf("reading[id:%s] add failed", baseReading.Id), err)
+	}
+
+	return reading, nil
+}
+
+// AddReading adds a reading to the database, then enforces c.Retention against the reading's device when
+// RetentionModeRingBuffer is configured.
+func (c *Client) AddReading(ctx context.Context, profileCache DeviceResourceLookup, r models.Reading) (reading models.Reading, edgeXerr errors.EdgeX) {
+	reading, edgeXerr = addReading(ctx, c.Client, c.BlobStore, profileCache, r)
+	if edgeXerr != nil {
+		return nil, edgeXerr
+	}
+
+	if c.Retention.Mode == RetentionModeRingBuffer {
+		if deviceName, _, ok := readingDeviceAndResourceName(reading); ok {
+			if enforceErr := c.EnforceRingBuffer(ctx, deviceName, c.Retention.MaxReadingsPerDevice, c.RetentionMetrics); enforceErr != nil {
+				c.loggingClient.Error(fmt.Sprintf("ring buffer enforcement failed.  Err: %s", enforceErr.DebugMessages()))
+			}
+		}
+	}
 
 	return reading, nil
 }
 
 // Remove a reading out of the database
-func deleteReadingById(conn redis.Conn, id string) (edgeXerr errors.EdgeX) {
+func deleteReadingById(ctx context.Context, conn redis.Cmdable, blobStore BlobStore, loggingClient logger.LoggingClient, id string) (edgeXerr errors.EdgeX) {
 	r := models.BaseReading{}
 	storedKey := readingStoredKey(id)
-	edgeXerr = getObjectById(conn, storedKey, &r)
+	edgeXerr = getObjectById(ctx, conn, storedKey, &r)
 	if edgeXerr != nil {
 		return edgeXerr
 	}
 
-	_ = conn.Send(MULTI)
-	_ = conn.Send(UNLINK, storedKey)
-	_ = conn.Send(ZREM, ReadingsCollection, storedKey)
-	_ = conn.Send(ZREM, ReadingsCollectionCreated, storedKey)
-	_ = conn.Send(ZREM, fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, r.DeviceName), storedKey)
-	_, err := conn.Do(EXEC)
-	if err != nil {
+	if blobStore != nil {
+		deleteReadingBlobsByIds(ctx, conn, blobStore, loggingClient, []string{id})
+	}
+
+	pipe := conn.TxPipeline()
+	pipe.Unlink(ctx, storedKey)
+	pipe.ZRem(ctx, ReadingsCollection, storedKey)
+	pipe.ZRem(ctx, ReadingsCollectionCreated, storedKey)
+	pipe.ZRem(ctx, fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, r.DeviceName), storedKey)
+	pipe.ZRem(ctx, fmt.Sprintf("%s:%s", ReadingsCollectionResourceName, r.ResourceName), storedKey)
+	pipe.ZRem(ctx, readingDeviceResourceIndexKey(r.DeviceName, r.ResourceName), storedKey)
+	if blobStore != nil {
+		pipe.Unlink(ctx, readingBlobRefKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return errors.NewCommonEdgeX(errors.KindDatabaseError, fmt.Sprintf("reading[id:%s] delete failed", id), err)
 	}
 
@@ -164,8 +277,8 @@ func checkReadingValue(b *models.BaseReading) errors.EdgeX {
 	return nil
 }
 
-func readingsByEventId(conn redis.Conn, eventId string) (readings []models.Reading, edgeXerr errors.EdgeX) {
-	objects, err := getObjectsByRange(conn, fmt.Sprintf("%s:%s", EventsCollectionReadings, eventId), 0, -1)
+func readingsByEventId(ctx context.Context, conn redis.Cmdable, eventId string) (readings []models.Reading, edgeXerr errors.EdgeX) {
+	objects, err := getObjectsByRange(ctx, conn, fmt.Sprintf("%s:%s", EventsCollectionReadings, eventId), 0, -1)
 	if errors.Kind(err) == errors.KindEntityDoesNotExist {
 		return // Empty Readings in an Event is not an error
 	} else if err != nil {
@@ -184,3 +297,29 @@ func readingsByEventId(conn redis.Conn, eventId string) (readings []models.Readi
 
 	return
 }
+
+// readingsByResourceName queries the v2:reading:resourceName:<name> index populated by addReading, returning
+// readings for resourceName ordered oldest to newest without requiring a full scan of the readings collection.
+func readingsByResourceName(ctx context.Context, conn redis.Cmdable, resourceName string, offset int, limit int) (readings []models.Reading, edgeXerr errors.EdgeX) {
+	end := offset + limit - 1
+	if limit == -1 {
+		end = -1
+	}
+	objects, err := getObjectsByRange(ctx, conn, fmt.Sprintf("%s:%s", ReadingsCollectionResourceName, resourceName), offset, end)
+	if errors.Kind(err) == errors.KindEntityDoesNotExist {
+		return []models.Reading{}, nil
+	} else if err != nil {
+		return nil, errors.NewCommonEdgeXWrapper(err)
+	}
+
+	readings = make([]models.Reading, len(objects))
+	for i, in := range objects {
+		sr := models.SimpleReading{}
+		if err := json.Unmarshal(in, &sr); err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindDatabaseError, "reading format parsing failed from the database", err)
+		}
+		readings[i] = sr
+	}
+
+	return readings, nil
+}
@@ -0,0 +1,163 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// AggregationFn identifies the folding function applied to the readings that fall within a single time bucket.
+type AggregationFn string
+
+const (
+	AggregationMin   AggregationFn = "min"
+	AggregationMax   AggregationFn = "max"
+	AggregationAvg   AggregationFn = "avg"
+	AggregationCount AggregationFn = "count"
+	AggregationLast  AggregationFn = "last"
+)
+
+// AggregatedReading is a single time bucket produced by ReadingsDownsampled.
+type AggregatedReading struct {
+	DeviceName   string
+	ResourceName string
+	BucketStart  int64
+	BucketEnd    int64
+	Value        float64
+	SampleCount  int
+}
+
+// ReadingsDownsampled returns readings for deviceName/resourceName between start and end (ms, inclusive),
+// bucketed into bucketMs-wide windows and folded down to one aggregate value per bucket according to agg.
+// It has no application-service or controller caller yet, so it is not reachable via any core-data v2 REST route.
+func (c *Client) ReadingsDownsampled(ctx context.Context, deviceName string, resourceName string, start int64, end int64, bucketMs int64, agg AggregationFn) (readings []AggregatedReading, edgeXerr errors.EdgeX) {
+	if bucketMs <= 0 {
+		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "bucketMs must be greater than zero", nil)
+	}
+
+	// query the device+resource index rather than the device index so readings for other resources on the same
+	// device are never fetched in the first place
+	storedKeys, err := c.Client.ZRangeByScore(ctx, readingDeviceResourceIndexKey(deviceName, resourceName), &redis.ZRangeBy{
+		Min: fmt.Sprintf("%d", start),
+		Max: fmt.Sprintf("%d", end),
+	}).Result()
+	if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to query reading index by score range", err)
+	}
+	if len(storedKeys) == 0 {
+		return []AggregatedReading{}, nil
+	}
+
+	// pipeline the fetches in batches of BatchSize so a large range does not block the connection with a single
+	// unbounded round trip
+	objects := make([][]byte, 0, len(storedKeys))
+	for i := 0; i < len(storedKeys); i += c.BatchSize {
+		j := i + c.BatchSize
+		if j > len(storedKeys) {
+			j = len(storedKeys)
+		}
+
+		pipe := c.Client.Pipeline()
+		cmds := make([]*redis.StringCmd, 0, j-i)
+		for _, key := range storedKeys[i:j] {
+			cmds = append(cmds, pipe.Get(ctx, key))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to retrieve reading during pipelined fetch", err)
+		}
+		for _, cmd := range cmds {
+			obj, err := cmd.Bytes()
+			if err != nil {
+				return nil, errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to retrieve reading during pipelined fetch", err)
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	buckets := make(map[int64][]float64)
+	for _, o := range objects {
+		sr := models.SimpleReading{}
+		if err := json.Unmarshal(o, &sr); err != nil {
+			// skip readings that are not simple numeric readings; they cannot be folded into an aggregate
+			continue
+		}
+		value, err := parseNumericReadingValue(sr)
+		if err != nil {
+			continue
+		}
+		bucketStart := (sr.Created / bucketMs) * bucketMs
+		buckets[bucketStart] = append(buckets[bucketStart], value)
+	}
+
+	readings = make([]AggregatedReading, 0, len(buckets))
+	for bucketStart, values := range buckets {
+		readings = append(readings, AggregatedReading{
+			DeviceName:   deviceName,
+			ResourceName: resourceName,
+			BucketStart:  bucketStart,
+			BucketEnd:    bucketStart + bucketMs,
+			Value:        foldReadingValues(values, agg),
+			SampleCount:  len(values),
+		})
+	}
+	sortAggregatedReadingsByBucketStart(readings)
+
+	return readings, nil
+}
+
+func parseNumericReadingValue(sr models.SimpleReading) (float64, error) {
+	var value float64
+	_, err := fmt.Sscanf(sr.Value, "%g", &value)
+	return value, err
+}
+
+func foldReadingValues(values []float64, agg AggregationFn) float64 {
+	switch agg {
+	case AggregationMin:
+		min := values[0]
+		for _, v := range values {
+			if v < min {
+				min = v
+			}
+		}
+		return min
+	case AggregationMax:
+		max := values[0]
+		for _, v := range values {
+			if v > max {
+				max = v
+			}
+		}
+		return max
+	case AggregationCount:
+		return float64(len(values))
+	case AggregationLast:
+		return values[len(values)-1]
+	case AggregationAvg:
+		fallthrough
+	default:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+}
+
+func sortAggregatedReadingsByBucketStart(readings []AggregatedReading) {
+	for i := 1; i < len(readings); i++ {
+		for j := i; j > 0 && readings[j].BucketStart < readings[j-1].BucketStart; j-- {
+			readings[j], readings[j-1] = readings[j-1], readings[j]
+		}
+	}
+}
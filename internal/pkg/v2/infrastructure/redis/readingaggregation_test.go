@@ -0,0 +1,58 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldReadingValues(t *testing.T) {
+	values := []float64{3, 1, 4, 1, 5}
+
+	tests := []struct {
+		name     string
+		agg      AggregationFn
+		expected float64
+	}{
+		{"min", AggregationMin, 1},
+		{"max", AggregationMax, 5},
+		{"count", AggregationCount, 5},
+		{"last", AggregationLast, 5},
+		{"avg", AggregationAvg, 2.8},
+		{"unknown falls back to avg", AggregationFn("bogus"), 2.8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, foldReadingValues(values, tt.agg))
+		})
+	}
+}
+
+func TestParseNumericReadingValue(t *testing.T) {
+	value, err := parseNumericReadingValue(models.SimpleReading{Value: "42.5"})
+	assert.NoError(t, err)
+	assert.Equal(t, 42.5, value)
+
+	_, err = parseNumericReadingValue(models.SimpleReading{Value: "not-a-number"})
+	assert.Error(t, err)
+}
+
+func TestSortAggregatedReadingsByBucketStart(t *testing.T) {
+	readings := []AggregatedReading{
+		{BucketStart: 30},
+		{BucketStart: 10},
+		{BucketStart: 20},
+	}
+
+	sortAggregatedReadingsByBucketStart(readings)
+
+	assert.Equal(t, []int64{10, 20, 30}, []int64{readings[0].BucketStart, readings[1].BucketStart, readings[2].BucketStart})
+}
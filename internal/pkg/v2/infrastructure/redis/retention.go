@@ -0,0 +1,169 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RetentionMode selects how the redis client keeps the volume of stored readings bounded.
+type RetentionMode string
+
+const (
+	// RetentionModeNone leaves readings in place until explicitly deleted, the historical behavior.
+	RetentionModeNone RetentionMode = ""
+	// RetentionModeTTL deletes readings older than TTL in the background via sweepExpiredReadings. No Redis key
+	// expiry is set on the stored readings themselves.
+	RetentionModeTTL RetentionMode = "ttl"
+	// RetentionModeRingBuffer keeps at most MaxReadingsPerDevice readings for each device, trimming the oldest
+	// after every write.
+	RetentionModeRingBuffer RetentionMode = "ringbuffer"
+)
+
+// RetentionConfig configures automatic reading retention.
+type RetentionConfig struct {
+	Mode RetentionMode
+	// TTL is the maximum age a reading is retained for under RetentionModeTTL.
+	TTL time.Duration
+	// SweepInterval is how often the background sweeper looks for expired readings under RetentionModeTTL.
+	SweepInterval time.Duration
+	// MaxReadingsPerDevice is the ring buffer capacity per device under RetentionModeRingBuffer.
+	MaxReadingsPerDevice int64
+}
+
+// RetentionMetrics tracks the effect of automatic retention so operators can observe it. All fields are updated
+// atomically and safe for concurrent use.
+type RetentionMetrics struct {
+	readingsEvicted   uint64
+	lastSweepDuration int64 // nanoseconds, read/written via atomic
+}
+
+// ReadingsEvicted returns the running total of readings removed by retention since the process started.
+func (m *RetentionMetrics) ReadingsEvicted() uint64 {
+	return atomic.LoadUint64(&m.readingsEvicted)
+}
+
+// LastSweepDuration returns how long the most recently completed TTL sweep took.
+func (m *RetentionMetrics) LastSweepDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&m.lastSweepDuration))
+}
+
+func (m *RetentionMetrics) recordEvicted(n int) {
+	atomic.AddUint64(&m.readingsEvicted, uint64(n))
+}
+
+func (m *RetentionMetrics) recordSweepDuration(d time.Duration) {
+	atomic.StoreInt64(&m.lastSweepDuration, int64(d))
+}
+
+// StartRetentionSweeper begins a background loop that evicts readings older than cfg.TTL under RetentionModeTTL,
+// returning a stop function. Under any other mode it returns a no-op stop function.
+func (c *Client) StartRetentionSweeper(ctx context.Context, cfg RetentionConfig, metrics *RetentionMetrics) (stop func()) {
+	if cfg.Mode != RetentionModeTTL {
+		return func() {}
+	}
+
+	sweepCtx, cancel := context.WithCancel(ctx)
+	ticker := time.NewTicker(cfg.SweepInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sweepCtx.Done():
+				return
+			case <-ticker.C:
+				if err := c.sweepExpiredReadings(sweepCtx, cfg.TTL, metrics); err != nil {
+					c.loggingClient.Error(fmt.Sprintf("reading retention sweep failed.  Err: %s", err.DebugMessages()))
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// sweepExpiredReadings finds every reading created before now-ttl and deletes it, in batches, via the existing
+// asyncDeleteReadingsByIds path so the sorted-set indexes stay consistent with the underlying reading documents.
+func (c *Client) sweepExpiredReadings(ctx context.Context, ttl time.Duration, metrics *RetentionMetrics) errors.EdgeX {
+	started := time.Now()
+	cutoff := started.Add(-ttl).UnixNano() / int64(time.Millisecond)
+
+	storedKeys, err := c.Client.ZRangeByScore(ctx, ReadingsCollectionCreated, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", cutoff),
+	}).Result()
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to query expired readings", err)
+	}
+	if len(storedKeys) == 0 {
+		metrics.recordSweepDuration(time.Since(started))
+		return nil
+	}
+
+	readingIds := make([]string, len(storedKeys))
+	for i, key := range storedKeys {
+		readingIds[i] = readingIdFromStoredKey(key)
+	}
+
+	for i := 0; i < len(readingIds); i += c.BatchSize {
+		j := i + c.BatchSize
+		if j > len(readingIds) {
+			j = len(readingIds)
+		}
+		metrics.recordEvicted(c.asyncDeleteReadingsByIds(ctx, readingIds[i:j]))
+	}
+
+	metrics.recordSweepDuration(time.Since(started))
+	return nil
+}
+
+// EnforceRingBuffer trims the oldest readings for deviceName once it holds more than maxPerDevice, as required
+// under RetentionModeRingBuffer. Callers invoke this after each addReading for the affected device.
+func (c *Client) EnforceRingBuffer(ctx context.Context, deviceName string, maxPerDevice int64, metrics *RetentionMetrics) errors.EdgeX {
+	deviceIndexKey := fmt.Sprintf("%s:%s", ReadingsCollectionDeviceName, deviceName)
+
+	count, err := c.Client.ZCard(ctx, deviceIndexKey).Result()
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to count readings for device", err)
+	}
+	overflow := count - maxPerDevice
+	if overflow <= 0 {
+		return nil
+	}
+
+	// oldest entries have the lowest Created score, i.e. the lowest ZRANGE index
+	storedKeys, err := c.Client.ZRange(ctx, deviceIndexKey, 0, overflow-1).Result()
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindDatabaseError, "failed to list oldest readings for device", err)
+	}
+	if len(storedKeys) == 0 {
+		return nil
+	}
+
+	readingIds := make([]string, len(storedKeys))
+	for i, key := range storedKeys {
+		readingIds[i] = readingIdFromStoredKey(key)
+	}
+
+	deletedCount := c.asyncDeleteReadingsByIds(ctx, readingIds)
+	if metrics != nil {
+		metrics.recordEvicted(deletedCount)
+	}
+
+	return nil
+}
+
+// readingIdFromStoredKey extracts the reading id suffix from a "v2:reading:<id>" stored key.
+func readingIdFromStoredKey(storedKey string) string {
+	return storedKey[len(ReadingsCollection)+1:]
+}
@@ -0,0 +1,42 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"fmt"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+// writeOnlyReadWrite is the DeviceResource.Properties.ReadWrite value that marks a resource as write-only, meaning
+// it accepts commands but never produces readings that should be persisted.
+const writeOnlyReadWrite = "W"
+
+// DeviceResourceLookup resolves the DeviceResource backing a reading so its ReadWrite mode can be enforced at
+// persistence time. It is satisfied by the device profile cache maintained by core-data.
+type DeviceResourceLookup interface {
+	DeviceResourceByName(deviceName, resourceName string) (models.DeviceResource, bool)
+}
+
+// checkResourceIsReadable rejects readings for a write-only ("W") DeviceResource. profileCache may be nil, in
+// which case no enforcement is performed.
+func checkResourceIsReadable(profileCache DeviceResourceLookup, deviceName, resourceName string) errors.EdgeX {
+	if profileCache == nil {
+		return nil
+	}
+
+	resource, found := profileCache.DeviceResourceByName(deviceName, resourceName)
+	if !found {
+		return nil
+	}
+
+	if resource.Properties.ReadWrite == writeOnlyReadWrite {
+		return errors.NewCommonEdgeX(errors.KindNotAllowed, fmt.Sprintf("resource[%s] of device[%s] is write-only and cannot be persisted as a reading", resourceName, deviceName), nil)
+	}
+
+	return nil
+}